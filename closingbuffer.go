@@ -0,0 +1,22 @@
+package httpcheck
+
+import "bytes"
+
+// ClosingBuffer wraps a bytes.Buffer with a no-op Close, so it can be used
+// as a request Body (io.ReadCloser) without requiring an actual connection
+// to close.
+type ClosingBuffer struct {
+	*bytes.Buffer
+}
+
+func (cb *ClosingBuffer) Close() error {
+	return nil
+}
+
+func newClosingBuffer(data []byte) *ClosingBuffer {
+	return &ClosingBuffer{bytes.NewBuffer(data)}
+}
+
+func newClosingBufferString(data string) *ClosingBuffer {
+	return &ClosingBuffer{bytes.NewBufferString(data)}
+}
@@ -1,26 +1,43 @@
 package httpcheck
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/ivpusic/golog"
 	"github.com/stretchr/testify/assert"
+	"github.com/xeipuuv/gojsonschema"
 )
 
 type (
 	Checker struct {
-		t        *testing.T
-		handler  http.Handler
-		request  *http.Request
-		response *http.Response
-		cookies  map[string]string
+		t           *testing.T
+		handler     http.Handler
+		request     *http.Request
+		response    *http.Response
+		cookies     map[string]*http.Cookie
+		jar         http.CookieJar
+		body        []byte
+		requestBody []byte
+		onServer    bool
+		useTLS      bool
+		server      *httptest.Server
 	}
 
 	Callback func(*http.Response)
@@ -36,7 +53,7 @@ func New(t *testing.T, handler http.Handler) *Checker {
 	instance := &Checker{
 		t:       t,
 		handler: handler,
-		cookies: map[string]string{},
+		cookies: map[string]*http.Cookie{},
 	}
 
 	return instance
@@ -50,6 +67,15 @@ func New(t *testing.T, handler http.Handler) *Checker {
 func (c *Checker) TestRequest(request *http.Request) *Checker {
 	assert.NotNil(c.t, request, "Request nil")
 
+	if request.Body != nil {
+		body, err := ioutil.ReadAll(request.Body)
+		assert.Nil(c.t, err)
+		request.Body.Close()
+
+		request.Body = newClosingBuffer(body)
+		c.requestBody = body
+	}
+
 	c.request = request
 	return c
 }
@@ -65,6 +91,25 @@ func (c *Checker) Test(method, path string) *Checker {
 	return c
 }
 
+// server //////////////////////////////////////////////////////////
+
+// Will make Check() spin up a real httptest.Server and send the request
+// through the full net/http client/server pipeline instead of calling
+// handler.ServeHTTP directly. Needed for anything that depends on real
+// request/response plumbing: RemoteAddr, TLS, chunked encoding, hijacking,
+// Flusher/SSE streaming. The default (false) stays the faster ServeHTTP mode.
+func (c *Checker) RunOnServer(enabled bool) *Checker {
+	c.onServer = enabled
+	return c
+}
+
+// Will make the real server started by RunOnServer(true) serve over TLS.
+// Has no effect unless RunOnServer(true) is also set.
+func (c *Checker) WithTLS(enabled bool) *Checker {
+	c.useTLS = enabled
+	return c
+}
+
 // headers ///////////////////////////////////////////////////////
 
 // Will put header on request
@@ -81,21 +126,333 @@ func (c *Checker) HasHeader(key, expectedValue string) *Checker {
 	return c
 }
 
+// cors ////////////////////////////////////////////////////////////
+
+// Will populate Origin, Access-Control-Request-Method and, if given,
+// Access-Control-Request-Headers in one call to build a CORS preflight request
+func (c *Checker) WithCORSPreflight(origin, method string, headers ...string) *Checker {
+	c.request.Header.Set("Origin", origin)
+	c.request.Header.Set("Access-Control-Request-Method", method)
+
+	if len(headers) > 0 {
+		c.request.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ", "))
+	}
+
+	return c
+}
+
+// Will check that the allowed methods, taken from Access-Control-Allow-Methods
+// (falling back to Allow), match methods as a set, regardless of order
+func (c *Checker) HasAllowedMethods(methods ...string) *Checker {
+	value := c.response.Header.Get("Access-Control-Allow-Methods")
+	if value == "" {
+		value = c.response.Header.Get("Allow")
+	}
+
+	return c.assertHeaderSet("Access-Control-Allow-Methods/Allow", value, methods)
+}
+
+// Will check if response has the expected Access-Control-Allow-Origin value
+func (c *Checker) HasAllowedOrigin(origin string) *Checker {
+	assert.Exactly(c.t, origin, c.response.Header.Get("Access-Control-Allow-Origin"))
+	return c
+}
+
+// Will check that Access-Control-Allow-Headers matches headers as a set,
+// regardless of order
+func (c *Checker) HasAllowedHeaders(headers ...string) *Checker {
+	value := c.response.Header.Get("Access-Control-Allow-Headers")
+	return c.assertHeaderSet("Access-Control-Allow-Headers", value, headers)
+}
+
+// Will check that Access-Control-Expose-Headers matches headers as a set,
+// regardless of order
+func (c *Checker) HasExposedHeaders(headers ...string) *Checker {
+	value := c.response.Header.Get("Access-Control-Expose-Headers")
+	return c.assertHeaderSet("Access-Control-Expose-Headers", value, headers)
+}
+
+func (c *Checker) assertHeaderSet(headerName, value string, want []string) *Checker {
+	got := map[string]bool{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			got[strings.ToLower(part)] = true
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, w := range want {
+		wanted[strings.ToLower(w)] = true
+	}
+
+	assert.Equal(c.t, wanted, got, fmt.Sprintf("%s header mismatch", headerName))
+	return c
+}
+
 // cookies ///////////////////////////////////////////////////////
 
-// Will put cookie on request
+// Will ckeck if response contains cookie with provided key and value
 func (c *Checker) HasCookie(key, expectedValue string) *Checker {
-	value, exists := c.cookies[key]
-	assert.True(c.t, exists && expectedValue == value)
+	cookie, exists := c.cookies[key]
+	assert.True(c.t, exists && expectedValue == cookie.Value)
 	return c
 }
 
-// Will ckeck if response contains cookie with provided key and value
-func (c *Checker) WithCookie(key, value string) *Checker {
-	c.request.AddCookie(&http.Cookie{
-		Name:  key,
-		Value: value,
-	})
+// Will check that the named cookie matches every attribute (Path, Domain,
+// Expires, MaxAge, Secure, HttpOnly, SameSite) of want, in addition to its value.
+func (c *Checker) HasCookieAttributes(name string, want *http.Cookie) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, want.Value, cookie.Value)
+	assert.Exactly(c.t, want.Path, cookie.Path)
+	assert.Exactly(c.t, want.Domain, cookie.Domain)
+	assert.Exactly(c.t, want.Expires, cookie.Expires)
+	assert.Exactly(c.t, want.MaxAge, cookie.MaxAge)
+	assert.Exactly(c.t, want.Secure, cookie.Secure)
+	assert.Exactly(c.t, want.HttpOnly, cookie.HttpOnly)
+	assert.Exactly(c.t, want.SameSite, cookie.SameSite)
+
+	return c
+}
+
+// Will check if the named cookie has the expected Path
+func (c *Checker) HasCookiePath(name, path string) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, path, cookie.Path)
+	return c
+}
+
+// Will check if the named cookie has the expected Domain
+func (c *Checker) HasCookieDomain(name, domain string) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, domain, cookie.Domain)
+	return c
+}
+
+// Will check if the named cookie has the expected Expires time
+func (c *Checker) HasCookieExpires(name string, expires time.Time) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, expires, cookie.Expires)
+	return c
+}
+
+// Will check if the named cookie has the expected MaxAge
+func (c *Checker) HasCookieMaxAge(name string, maxAge int) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, maxAge, cookie.MaxAge)
+	return c
+}
+
+// Will check if the named cookie has the expected Secure flag
+func (c *Checker) HasCookieSecure(name string, secure bool) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, secure, cookie.Secure)
+	return c
+}
+
+// Will check if the named cookie has the expected HttpOnly flag
+func (c *Checker) HasCookieHTTPOnly(name string, httpOnly bool) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, httpOnly, cookie.HttpOnly)
+	return c
+}
+
+// Will check if the named cookie has the expected SameSite mode
+func (c *Checker) HasCookieSameSite(name string, sameSite http.SameSite) *Checker {
+	cookie, exists := c.cookies[name]
+	if !assert.True(c.t, exists, fmt.Sprintf("cookie %q not found", name)) {
+		return c
+	}
+
+	assert.Exactly(c.t, sameSite, cookie.SameSite)
+	return c
+}
+
+// Will put cookie on request. Accepts either a (key, value string) pair for
+// the common case, or a single *http.Cookie when attributes like Path,
+// Domain or Expires need to be set too - Go has no method overloading, so
+// the arity/type of args picks which form was used.
+func (c *Checker) WithCookie(args ...interface{}) *Checker {
+	switch len(args) {
+	case 1:
+		cookie, ok := args[0].(*http.Cookie)
+		if !assert.True(c.t, ok, "WithCookie(cookie) expects a *http.Cookie") {
+			return c
+		}
+
+		c.request.AddCookie(cookie)
+	case 2:
+		key, keyOk := args[0].(string)
+		if !assert.True(c.t, keyOk, "WithCookie(key, value): key must be a string") {
+			return c
+		}
+
+		value, valueOk := args[1].(string)
+		if !assert.True(c.t, valueOk, "WithCookie(key, value): value must be a string") {
+			return c
+		}
+
+		c.request.AddCookie(&http.Cookie{
+			Name:  key,
+			Value: value,
+		})
+	default:
+		assert.Fail(c.t, "WithCookie expects either (key, value string) or (*http.Cookie)")
+	}
+
+	return c
+}
+
+// sessions /////////////////////////////////////////////////////
+
+// Will enable (or disable) a persistent cookie jar, so cookies received on one
+// Check() are automatically resent, scoped by URL, on subsequent requests -
+// useful for simulating a login flow across several requests
+func (c *Checker) PersistCookies(enabled bool) *Checker {
+	if !enabled {
+		c.jar = nil
+		return c
+	}
+
+	jar, err := cookiejar.New(nil)
+	assert.Nil(c.t, err)
+	c.jar = jar
+
+	return c
+}
+
+// Will use the provided cookie jar instead of creating a new one, e.g. to
+// resume a session loaded with LoadCookiesFromFile
+func (c *Checker) WithCookieJar(jar http.CookieJar) *Checker {
+	c.jar = jar
+	return c
+}
+
+// Will load cookies from a file in the Netscape cookies.txt format into the
+// checker's cookie jar, enabling it first if necessary
+func (c *Checker) LoadCookiesFromFile(path string) *Checker {
+	file, err := os.Open(path)
+	if !assert.Nil(c.t, err) {
+		return c
+	}
+	defer file.Close()
+
+	if c.jar == nil {
+		c.PersistCookies(true)
+	}
+
+	byURL := map[string][]*http.Cookie{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		} else if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+
+		domain, path, secure, expires, name, value := fields[0], fields[2], fields[3] == "TRUE", fields[4], fields[5], fields[6]
+
+		cookie := &http.Cookie{
+			Name:     name,
+			Value:    value,
+			Path:     path,
+			Domain:   domain,
+			Secure:   secure,
+			HttpOnly: httpOnly,
+		}
+
+		if unix, err := strconv.ParseInt(expires, 10, 64); err == nil && unix > 0 {
+			cookie.Expires = time.Unix(unix, 0)
+		}
+
+		scheme := "http"
+		if secure {
+			scheme = "https"
+		}
+		base := scheme + "://" + strings.TrimPrefix(domain, ".")
+		byURL[base] = append(byURL[base], cookie)
+	}
+	assert.Nil(c.t, scanner.Err())
+
+	for base, cookies := range byURL {
+		u, err := url.Parse(base)
+		if assert.Nil(c.t, err) {
+			c.jar.SetCookies(u, cookies)
+		}
+	}
+
+	return c
+}
+
+// Will save every cookie seen so far to a file in the Netscape cookies.txt
+// format, so the session can be replayed later with LoadCookiesFromFile
+func (c *Checker) SaveCookiesToFile(path string) *Checker {
+	file, err := os.Create(path)
+	if !assert.Nil(c.t, err) {
+		return c
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, "# Netscape HTTP Cookie File")
+
+	host := c.jarURL().Hostname()
+	for _, cookie := range c.cookies {
+		domain := cookie.Domain
+		if domain == "" {
+			domain = host
+		}
+
+		expires := int64(0)
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+
+		if cookie.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+
+		fmt.Fprintf(file, "%s\tTRUE\t%s\t%s\t%d\t%s\t%s\n",
+			domain, cookie.Path, strings.ToUpper(strconv.FormatBool(cookie.Secure)), expires, cookie.Name, cookie.Value)
+	}
 
 	return c
 }
@@ -119,12 +476,42 @@ func (c *Checker) WithJson(value interface{}) *Checker {
 
 // Will ckeck if body contains json with provided value
 func (c *Checker) HasJson(value interface{}) *Checker {
-	body, err := ioutil.ReadAll(c.response.Body)
-	assert.Nil(c.t, err)
-
 	valueBytes, err := json.Marshal(value)
 	assert.Nil(c.t, err)
-	assert.Equal(c.t, string(valueBytes), string(body))
+	assert.Equal(c.t, string(valueBytes), string(c.body))
+
+	return c
+}
+
+// json schema //////////////////////////////////////////////////
+
+// Will check if the response body validates against the given JSON Schema
+// document. Unlike HasJson, this does not require exact byte-for-byte
+// marshaling equality, so it tolerates extra fields or reordered keys.
+func (c *Checker) HasJsonSchema(schema string) *Checker {
+	return c.hasJsonSchema(gojsonschema.NewStringLoader(schema))
+}
+
+// Same as HasJsonSchema, but loads the schema document from a file on disk
+func (c *Checker) HasJsonSchemaFile(path string) *Checker {
+	return c.hasJsonSchema(gojsonschema.NewReferenceLoader("file://" + path))
+}
+
+func (c *Checker) hasJsonSchema(schemaLoader gojsonschema.JSONLoader) *Checker {
+	documentLoader := gojsonschema.NewBytesLoader(c.body)
+
+	result, err := gojsonschema.Validate(schemaLoader, documentLoader)
+	if !assert.Nil(c.t, err) {
+		return c
+	}
+
+	if !result.Valid() {
+		errs := make([]string, 0, len(result.Errors()))
+		for _, e := range result.Errors() {
+			errs = append(errs, fmt.Sprintf("%s: %s", e.Field(), e.Description()))
+		}
+		assert.Fail(c.t, "response body does not match JSON schema", strings.Join(errs, "; "))
+	}
 
 	return c
 }
@@ -140,12 +527,9 @@ func (c *Checker) WithXml(value interface{}) *Checker {
 
 // Will ckeck if body contains xml with provided value
 func (c *Checker) HasXml(value interface{}) *Checker {
-	body, err := ioutil.ReadAll(c.response.Body)
-	assert.Nil(c.t, err)
-
 	valueBytes, err := xml.Marshal(value)
 	assert.Nil(c.t, err)
-	assert.Equal(c.t, string(valueBytes), string(body))
+	assert.Equal(c.t, string(valueBytes), string(c.body))
 
 	return c
 }
@@ -155,22 +539,20 @@ func (c *Checker) HasXml(value interface{}) *Checker {
 // Adds the []byte data to the body
 func (c *Checker) WithBody(body []byte) *Checker {
 	c.request.Body = newClosingBuffer(body)
+	c.requestBody = body
 	return c
 }
 
 // Will check if body contains provided []byte data
 func (c *Checker) HasBody(body []byte) *Checker {
-	responseBody, err := ioutil.ReadAll(c.response.Body)
-
-	assert.Nil(c.t, err)
-	assert.Equal(c.t, body, responseBody)
-
+	assert.Equal(c.t, body, c.body)
 	return c
 }
 
 // Adds the string to the body
 func (c *Checker) WithString(body string) *Checker {
 	c.request.Body = newClosingBufferString(body)
+	c.requestBody = []byte(body)
 	return c
 }
 
@@ -180,24 +562,61 @@ func (c *Checker) HasString(body string) *Checker {
 	return c.HasBody([]byte(body))
 }
 
-func (c *Checker) handleCookies(r *http.Response) {
-	if header, exist := r.Header["Set-Cookie"]; exist {
-		for _, str := range header {
-			if ind := strings.Index(str, "="); ind > 0 {
-				c.cookies[str[0:ind]] = str[ind+1 : len(str)]
-			} else {
-				panic("did not find = in cookie string")
-			}
-		}
+// html ////////////////////////////////////////////////////////
+
+func (c *Checker) parseHTML() *goquery.Document {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(c.body))
+	assert.Nil(c.t, err)
+	return doc
+}
+
+// Will check if the body contains at least one element matching the CSS selector
+func (c *Checker) HasSelector(selector string) *Checker {
+	doc := c.parseHTML()
+	assert.True(c.t, doc.Find(selector).Length() > 0, fmt.Sprintf("no element matches selector %q", selector))
+	return c
+}
+
+// Will check if the first element matching the CSS selector has the expected text
+func (c *Checker) HasSelectorText(selector, expected string) *Checker {
+	doc := c.parseHTML()
+	selection := doc.Find(selector)
+	if !assert.True(c.t, selection.Length() > 0, fmt.Sprintf("no element matches selector %q", selector)) {
+		return c
 	}
+
+	assert.Equal(c.t, expected, strings.TrimSpace(selection.First().Text()))
+	return c
 }
 
-func (c *Checker) generateCookieString() string {
-	str := ""
-	for name, val := range c.cookies {
-		str += fmt.Sprintf("%s=%s;", name, val)
+// Will check if the first element matching the CSS selector has the expected attribute value
+func (c *Checker) HasSelectorAttribute(selector, attr, value string) *Checker {
+	doc := c.parseHTML()
+	selection := doc.Find(selector)
+	if !assert.True(c.t, selection.Length() > 0, fmt.Sprintf("no element matches selector %q", selector)) {
+		return c
+	}
+
+	got, exists := selection.First().Attr(attr)
+	assert.True(c.t, exists, fmt.Sprintf("element matching %q has no %q attribute", selector, attr))
+	assert.Equal(c.t, value, got)
+
+	return c
+}
+
+// Will check if exactly n elements match the CSS selector
+func (c *Checker) HasSelectorCount(selector string, n int) *Checker {
+	doc := c.parseHTML()
+	assert.Exactly(c.t, n, doc.Find(selector).Length())
+	return c
+}
+
+// Parses Set-Cookie headers the same way net/http does, so attributes like
+// Path, Domain, Expires, Max-Age, Secure, HttpOnly and SameSite are preserved
+func (c *Checker) handleCookies(r *http.Response) {
+	for _, cookie := range r.Cookies() {
+		c.cookies[cookie.Name] = cookie
 	}
-	return str
 }
 
 // Will make reqeust to built request object.
@@ -205,23 +624,181 @@ func (c *Checker) generateCookieString() string {
 // Responsibility of this method is also to start and stop HTTP server
 func (c *Checker) Check() *Checker {
 
+	// RunOnServer rewrites c.request.URL's scheme/host to the real server's
+	// address; do that before computing jarURL() below, so the jar is read
+	// and written under the same key the request is actually sent to rather
+	// than the http://localhost default
+	if c.onServer {
+		c.startServer()
+	}
+
+	jarURL := c.jarURL()
+
 	// set cookies
-	c.request.Header.Set("Cookie", c.generateCookieString())
+	if c.jar != nil {
+		for _, cookie := range c.jar.Cookies(jarURL) {
+			c.request.AddCookie(cookie)
+		}
+	} else {
+		// Cookies set explicitly via WithCookie are already on c.request.Header
+		// (AddCookie appends); append cookies seen on a previous response on
+		// top of them instead of overwriting the header
+		for _, cookie := range c.cookies {
+			c.request.AddCookie(cookie)
+		}
+	}
+
+	var resp *http.Response
+	if c.onServer {
+		resp = c.checkOnServer()
+	} else {
+		resp = c.checkInProcess()
+	}
+
+	// buffer the body once so Has* assertions can be chained and each
+	// reads from the buffer instead of draining a single-shot stream
+	body, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(c.t, err)
+	c.body = body
+	resp.Body = NewReadCloser(bytes.NewReader(body))
+
+	c.handleCookies(resp)
+
+	if c.jar != nil {
+		c.jar.SetCookies(jarURL, resp.Cookies())
+	}
+
+	c.response = resp
+
+	return c
+}
 
+// Fast path: calls handler.ServeHTTP directly against a ResponseRecorder,
+// skipping the real net/http transport
+func (c *Checker) checkInProcess() *http.Response {
 	recorder := httptest.NewRecorder()
 	c.handler.ServeHTTP(recorder, c.request)
 
-	resp := &http.Response{
+	return &http.Response{
 		StatusCode: recorder.Code,
 		Body:       NewReadCloser(recorder.Body),
 		Header:     recorder.Header(),
 	}
-	c.handleCookies(resp)
-	c.response = resp
+}
+
+// Full path: spins up a real httptest.Server and sends the request through
+// http.Client, so things the recorder can't express - RemoteAddr, TLS,
+// chunked encoding, hijacking, Flusher/SSE streaming - behave as in production.
+// The server is NOT closed here: Close() blocks until every handler still
+// running against it returns, which for a streaming/hijacking handler only
+// happens once its connection has been drained by the client. Closing it
+// before Check() has read the response body would deadlock exactly the
+// handlers this mode exists to support, so it's closed via t.Cleanup instead,
+// once the test (and all its assertions) are done with it.
+func (c *Checker) checkOnServer() *http.Response {
+	resp, err := c.server.Client().Do(c.request)
+	assert.Nil(c.t, err)
+
+	return resp
+}
+
+// Starts the real httptest.Server backing RunOnServer(true), and points
+// c.request at it. The server is started once per Checker and reused across
+// every subsequent Check() call on it, rather than recreated per call: a
+// fresh server listens on a new ephemeral port each time, and a Checker with
+// a persistent cookie jar needs requests to keep landing on the same
+// host:port for jar-scoped cookies set by one request to still apply to the
+// next.
+func (c *Checker) startServer() {
+	if c.server == nil {
+		if c.useTLS {
+			c.server = httptest.NewTLSServer(c.handler)
+		} else {
+			c.server = httptest.NewServer(c.handler)
+		}
+		c.t.Cleanup(c.server.Close)
+	}
+
+	serverURL, err := url.Parse(c.server.URL)
+	assert.Nil(c.t, err)
+
+	c.request.URL.Scheme = serverURL.Scheme
+	c.request.URL.Host = serverURL.Host
+}
+
+// Since requests made with Test() often carry a bare path ("/login") rather
+// than an absolute URL, default the scheme/host so the cookie jar has
+// something to scope cookies to
+func (c *Checker) jarURL() *url.URL {
+	u := *c.request.URL
+	if u.Scheme == "" {
+		u.Scheme = "http"
+	}
+	if u.Host == "" {
+		u.Host = "localhost"
+	}
+	return &u
+}
+
+// debugging ///////////////////////////////////////////////////
+
+// Will write a dump of the built request, as it would be sent over the wire,
+// to w. Safe to call before or after Check(): the body dumped is always the
+// one built by WithBody/WithJson/WithXml/WithString, not whatever is left
+// unread on c.request.Body, which Check() (or, in RunOnServer mode, the real
+// http.Client transport) may already have drained.
+func (c *Checker) DumpRequest(w io.Writer) *Checker {
+	request := c.request
+	if c.requestBody != nil {
+		clone := *c.request
+		clone.Body = newClosingBuffer(c.requestBody)
+		request = &clone
+	}
+
+	dump, err := httputil.DumpRequest(request, true)
+	assert.Nil(c.t, err)
+
+	_, err = w.Write(dump)
+	assert.Nil(c.t, err)
 
 	return c
 }
 
+// Will write a dump of the response obtained from Check() to w
+func (c *Checker) DumpResponse(w io.Writer) *Checker {
+	dump, err := httputil.DumpResponse(c.response, true)
+	assert.Nil(c.t, err)
+
+	_, err = w.Write(dump)
+	assert.Nil(c.t, err)
+
+	return c
+}
+
+// Will render the built request as an equivalent curl command, useful for
+// reproducing a failing chained assertion outside the test
+func (c *Checker) ToCurl() string {
+	parts := []string{"curl", "-X", shellQuote(c.request.Method)}
+
+	for key, values := range c.request.Header {
+		for _, value := range values {
+			parts = append(parts, "-H", shellQuote(fmt.Sprintf("%s: %s", key, value)))
+		}
+	}
+
+	if len(c.requestBody) > 0 {
+		parts = append(parts, "-d", shellQuote(string(c.requestBody)))
+	}
+
+	parts = append(parts, shellQuote(c.request.URL.String()))
+
+	return strings.Join(parts, " ")
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
 // Will call provided callback function with current response
 func (c *Checker) Cb(cb Callback) {
 	cb(c.response)
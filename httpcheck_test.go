@@ -0,0 +1,311 @@
+package httpcheck
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieHeaderNotClobberedWithoutJar(t *testing.T) {
+	var gotCookie string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotCookie = r.Header.Get("Cookie")
+	})
+
+	New(t, handler).
+		Test("GET", "/").
+		WithCookie("session", "abc").
+		Check()
+
+	if gotCookie != "session=abc" {
+		t.Fatalf("expected Cookie header %q, got %q", "session=abc", gotCookie)
+	}
+}
+
+func TestPersistCookiesAcrossRequests(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "xyz", Path: "/"})
+			return
+		}
+
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "xyz" {
+			t.Errorf("expected session cookie xyz on second request, got err=%v cookie=%v", err, cookie)
+		}
+	})
+
+	checker := New(t, handler).PersistCookies(true)
+
+	checker.Test("GET", "/login").Check()
+	checker.Test("GET", "/profile").Check()
+}
+
+func TestWithCookieAcceptsKeyValueOrFullCookie(t *testing.T) {
+	var gotCookies []*http.Cookie
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotCookies = r.Cookies()
+	})
+
+	New(t, handler).
+		Test("GET", "/").
+		WithCookie("session", "abc").
+		WithCookie(&http.Cookie{Name: "pref", Value: "dark"}).
+		Check()
+
+	if len(gotCookies) != 2 {
+		t.Fatalf("expected 2 cookies, got %d: %v", len(gotCookies), gotCookies)
+	}
+
+	if gotCookies[0].Name != "session" || gotCookies[0].Value != "abc" {
+		t.Errorf("expected first cookie session=abc, got %s=%s", gotCookies[0].Name, gotCookies[0].Value)
+	}
+
+	if gotCookies[1].Name != "pref" || gotCookies[1].Value != "dark" {
+		t.Errorf("expected second cookie pref=dark, got %s=%s", gotCookies[1].Name, gotCookies[1].Value)
+	}
+}
+
+func TestHasCookieAttributes(t *testing.T) {
+	expires := time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "session",
+			Value:    "abc",
+			Path:     "/app",
+			Domain:   "example.com",
+			Expires:  expires,
+			MaxAge:   3600,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		})
+	})
+
+	New(t, handler).
+		Test("GET", "/").
+		Check().
+		HasCookie("session", "abc").
+		HasCookieAttributes("session", &http.Cookie{
+			Value:    "abc",
+			Path:     "/app",
+			Domain:   "example.com",
+			Expires:  expires,
+			MaxAge:   3600,
+			Secure:   true,
+			HttpOnly: true,
+			SameSite: http.SameSiteStrictMode,
+		}).
+		HasCookiePath("session", "/app").
+		HasCookieDomain("session", "example.com").
+		HasCookieExpires("session", expires).
+		HasCookieMaxAge("session", 3600).
+		HasCookieSecure("session", true).
+		HasCookieHTTPOnly("session", true).
+		HasCookieSameSite("session", http.SameSiteStrictMode)
+}
+
+func TestHasSelector(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><h1 id="title" class="big">Hello</h1><p>one</p><p>two</p></body></html>`)
+	})
+
+	New(t, handler).
+		Test("GET", "/").
+		Check().
+		HasSelector("h1#title").
+		HasSelectorText("h1#title", "Hello").
+		HasSelectorAttribute("h1#title", "class", "big").
+		HasSelectorCount("p", 2)
+}
+
+func TestHasBodyReadsBufferedBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	})
+
+	New(t, handler).
+		Test("GET", "/").
+		Check().
+		HasBody([]byte("hello")).
+		HasString("hello")
+}
+
+func TestHasJsonSchema(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "Alice", "age": 30}`)
+	})
+
+	schema := `{
+		"type": "object",
+		"required": ["name", "age"],
+		"properties": {
+			"name": {"type": "string"},
+			"age": {"type": "integer"}
+		}
+	}`
+
+	New(t, handler).
+		Test("GET", "/").
+		Check().
+		HasJsonSchema(schema)
+}
+
+func TestHasJsonSchemaFile(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "schema.json")
+	err := ioutil.WriteFile(schemaFile, []byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"name": {"type": "string"}
+		}
+	}`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write schema file: %v", err)
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "Bob"}`)
+	})
+
+	New(t, handler).
+		Test("GET", "/").
+		Check().
+		HasJsonSchemaFile(schemaFile)
+}
+
+func TestDumpRequestIncludesBuiltBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	})
+
+	var buf bytes.Buffer
+	New(t, handler).
+		Test("POST", "/echo").
+		WithString("payload").
+		Check().
+		DumpRequest(&buf)
+
+	dump := buf.String()
+	if !strings.Contains(dump, "POST /echo") {
+		t.Errorf("expected dump to contain request line, got %q", dump)
+	}
+	if !strings.Contains(dump, "payload") {
+		t.Errorf("expected dump to contain the request body, got %q", dump)
+	}
+}
+
+func TestDumpResponse(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "pong")
+	})
+
+	var buf bytes.Buffer
+	New(t, handler).
+		Test("GET", "/ping").
+		Check().
+		DumpResponse(&buf)
+
+	if !strings.Contains(buf.String(), "pong") {
+		t.Errorf("expected dump to contain the response body, got %q", buf.String())
+	}
+}
+
+func TestToCurl(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	})
+
+	curl := New(t, handler).
+		Test("POST", "/echo").
+		WithHeader("Content-Type", "text/plain").
+		WithString("hello world").
+		Check().
+		ToCurl()
+
+	for _, want := range []string{"curl", "-X", "'POST'", "'Content-Type: text/plain'", "'hello world'", "/echo"} {
+		if !strings.Contains(curl, want) {
+			t.Errorf("expected curl command to contain %q, got %q", want, curl)
+		}
+	}
+}
+
+func TestCORSPreflightAssertions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "https://example.com" {
+			t.Errorf("expected Origin header, got %q", r.Header.Get("Origin"))
+		}
+		if r.Header.Get("Access-Control-Request-Method") != "PUT" {
+			t.Errorf("expected Access-Control-Request-Method PUT, got %q", r.Header.Get("Access-Control-Request-Method"))
+		}
+
+		w.Header().Set("Access-Control-Allow-Methods", "PUT, GET, POST")
+		w.Header().Set("Access-Control-Allow-Origin", "https://example.com")
+		w.Header().Set("Access-Control-Allow-Headers", "X-Custom, Content-Type")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
+	})
+
+	New(t, handler).
+		Test("OPTIONS", "/").
+		WithCORSPreflight("https://example.com", "PUT", "X-Custom").
+		Check().
+		HasAllowedMethods("GET", "POST", "PUT").
+		HasAllowedOrigin("https://example.com").
+		HasAllowedHeaders("Content-Type", "X-Custom").
+		HasExposedHeaders("X-Request-Id")
+}
+
+func TestPersistCookiesAcrossRequestsOnServer(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "xyz", Path: "/"})
+			return
+		}
+
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "xyz" {
+			t.Errorf("expected session cookie xyz on second request, got err=%v cookie=%v", err, cookie)
+		}
+	})
+
+	checker := New(t, handler).PersistCookies(true).RunOnServer(true)
+
+	checker.Test("GET", "/login").Check()
+	checker.Test("GET", "/profile").Check()
+}
+
+func TestRunOnServerStreamsWithoutDeadlock(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Error("expected ResponseWriter to support flushing in RunOnServer mode")
+			return
+		}
+
+		fmt.Fprint(w, "chunk1")
+		flusher.Flush()
+		fmt.Fprint(w, "chunk2")
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		New(t, handler).
+			RunOnServer(true).
+			Test("GET", "/").
+			Check().
+			HasStatus(http.StatusOK).
+			HasBody([]byte("chunk1chunk2"))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Check() deadlocked in RunOnServer mode")
+	}
+}